@@ -0,0 +1,147 @@
+package serf
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// messageType is a single byte prefix on every gossiped message that
+// identifies how the remainder of the payload should be decoded.
+type messageType uint8
+
+const (
+	messageLeaveType messageType = iota
+	messageRemoveType
+	messageUserEventType
+	messageAliveType
+	messageSuspectType
+)
+
+// broadcast implements memberlist.Broadcast, letting Serf queue its
+// own messages (leave, remove, user events) onto the same gossip
+// transport memberlist uses for membership updates.
+type broadcast struct {
+	msg    []byte
+	notify chan struct{}
+}
+
+func (b *broadcast) Invalidates(other memberlist.Broadcast) bool {
+	return false
+}
+
+func (b *broadcast) Message() []byte {
+	return b.msg
+}
+
+func (b *broadcast) Finished() {
+	if b.notify != nil {
+		close(b.notify)
+	}
+}
+
+// encodeMessage gob-encodes msg and prefixes it with its message
+// type so NotifyMsg can dispatch it correctly on receive.
+func encodeMessage(t messageType, msg interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 128))
+	buf.WriteByte(uint8(t))
+	if err := gob.NewEncoder(buf).Encode(msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// queueBroadcast encodes msg and hands it to the broadcast queue so
+// it propagates to the rest of the cluster via gossip.
+func (s *Serf) queueBroadcast(t messageType, msg interface{}) error {
+	raw, err := encodeMessage(t, msg)
+	if err != nil {
+		return err
+	}
+	s.broadcasts.QueueBroadcast(&broadcast{msg: raw})
+	return nil
+}
+
+// NodeMeta is part of memberlist.Delegate and supplies the opaque
+// role metadata gossiped alongside this node's membership record.
+func (s *Serf) NodeMeta(limit int) []byte {
+	return []byte(s.conf.Role)
+}
+
+// NotifyMsg is part of memberlist.Delegate and is invoked whenever a
+// gossip message other than a membership update is received.
+func (s *Serf) NotifyMsg(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+
+	t := messageType(buf[0])
+	dec := gob.NewDecoder(bytes.NewReader(buf[1:]))
+
+	switch t {
+	case messageLeaveType:
+		var l leave
+		if err := dec.Decode(&l); err != nil {
+			log.Printf("[ERR] serf: failed to decode leave message: %v", err)
+			return
+		}
+		if s.intendLeave(&l) {
+			s.queueBroadcast(messageLeaveType, &l)
+		}
+
+	case messageRemoveType:
+		var r remove
+		if err := dec.Decode(&r); err != nil {
+			log.Printf("[ERR] serf: failed to decode remove message: %v", err)
+			return
+		}
+		if s.forceRemove(&r) {
+			s.queueBroadcast(messageRemoveType, &r)
+		}
+
+	case messageUserEventType:
+		var ev userEvent
+		if err := dec.Decode(&ev); err != nil {
+			log.Printf("[ERR] serf: failed to decode user event: %v", err)
+			return
+		}
+		s.handleRemoteUserEvent(ev)
+
+	case messageAliveType:
+		var a alive
+		if err := dec.Decode(&a); err != nil {
+			log.Printf("[ERR] serf: failed to decode alive message: %v", err)
+			return
+		}
+		if s.handleAlive(&a) {
+			s.queueBroadcast(messageAliveType, &a)
+		}
+
+	case messageSuspectType:
+		var sp suspect
+		if err := dec.Decode(&sp); err != nil {
+			log.Printf("[ERR] serf: failed to decode suspect message: %v", err)
+			return
+		}
+		if s.handleSuspect(&sp) {
+			s.queueBroadcast(messageSuspectType, &sp)
+		}
+
+	default:
+		log.Printf("[ERR] serf: received message of unknown type %d", t)
+	}
+}
+
+// GetBroadcasts is part of memberlist.Delegate and supplies pending
+// gossip messages up to the given byte budget.
+func (s *Serf) GetBroadcasts(overhead, limit int) [][]byte {
+	return s.broadcasts.GetBroadcasts(overhead, limit)
+}
+
+// LocalState and MergeRemoteState are part of memberlist.Delegate.
+// Serf carries all of its state through join/gossip/leave messages
+// rather than push/pull state transfer, so both are no-ops.
+func (s *Serf) LocalState(join bool) []byte         { return nil }
+func (s *Serf) MergeRemoteState(buf []byte, join bool) {}
@@ -0,0 +1,87 @@
+package serf
+
+import (
+	"time"
+
+	"github.com/armon/go-metrics"
+)
+
+// Config is used to configure a Serf instance.
+type Config struct {
+	// NodeName is the name of this node. This must be unique in the cluster.
+	NodeName string
+
+	// Role is opaque application metadata gossiped alongside this
+	// node's membership record.
+	Role string
+
+	// Delegate is invoked with coalesced membership events. May be nil.
+	Delegate Delegate
+
+	// MinQuiescentTime is the amount of time that must elapse with no
+	// new changes before the coalesced updates are delivered.
+	MinQuiescentTime time.Duration
+
+	// MaxCoalesceTime is the maximum amount of time coalesceUpdates will
+	// wait before delivering updates, regardless of quiescence.
+	MaxCoalesceTime time.Duration
+
+	// LeaveTimeout is how long an intent to leave is honored before the
+	// member is reset back to alive if it hasn't actually left.
+	LeaveTimeout time.Duration
+
+	// ReapInterval is the interval at which the reap goroutine checks
+	// for tombstoned members that are old enough to be removed.
+	ReapInterval time.Duration
+
+	// TombstoneTimeout is how long a failed member is kept around
+	// before it is reaped from the member list.
+	TombstoneTimeout time.Duration
+
+	// LeaveTombstoneTimeout is how long a gracefully left member is
+	// kept around before it is reaped from the member list.
+	LeaveTombstoneTimeout time.Duration
+
+	// SnapshotPath, if set, is the path to a file where Serf persists
+	// membership changes so that a restarted node can recover its
+	// previous view of the cluster and automatically rejoin it.
+	SnapshotPath string
+
+	// UserEventSizeLimit caps the payload size, in bytes, accepted by
+	// Serf.UserEvent. Defaults to defaultUserEventSizeLimit when zero.
+	UserEventSizeLimit int
+
+	// SuspicionMult is the number of distinct members that must
+	// corroborate a failure claim about a peer before it is escalated
+	// from StatusSuspect to StatusFailed. Defaults to 3 when zero.
+	SuspicionMult int
+
+	// SuspicionTimeout bounds how long a member may remain
+	// StatusSuspect before it is escalated to StatusFailed outright,
+	// guaranteeing forward progress in clusters too small to ever
+	// gather SuspicionMult distinct corroborating reports. Defaults to
+	// 5 seconds when zero.
+	SuspicionTimeout time.Duration
+
+	// MetricLabels are attached to every metric Serf emits, letting a
+	// multi-cluster deployment tag series by cluster, region, etc.
+	MetricLabels []metrics.Label
+
+	// SubscriberBufferSize bounds how many undelivered events a
+	// subscriber's channel may hold before further events are dropped
+	// for it rather than blocking the publisher. Defaults to
+	// subscriberBuffer when zero.
+	SubscriberBufferSize int
+}
+
+// DefaultConfig returns a Config with the default settings.
+func DefaultConfig() *Config {
+	return &Config{
+		MinQuiescentTime:      100 * time.Millisecond,
+		MaxCoalesceTime:       3 * time.Second,
+		LeaveTimeout:          5 * time.Second,
+		ReapInterval:          15 * time.Second,
+		TombstoneTimeout:      24 * time.Hour,
+		LeaveTombstoneTimeout: 24 * time.Hour,
+	}
+}
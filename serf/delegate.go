@@ -0,0 +1,13 @@
+package serf
+
+// Delegate is the interface that client code implements to receive
+// notifications about cluster membership changes as they are
+// coalesced by changeHandler.
+type Delegate interface {
+	MembersJoined(members []*Member)
+	MembersLeft(members []*Member)
+	MembersFailed(members []*Member)
+	MembersPartitioned(members []*Member)
+	MembersReaped(members []*Member)
+	UserEvent(name string, ltime uint64, payload []byte)
+}
@@ -1,8 +1,11 @@
 package serf
 
 import (
-	"github.com/hashicorp/memberlist"
+	"math"
 	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/memberlist"
 )
 
 type statusChange struct {
@@ -11,11 +14,53 @@ type statusChange struct {
 	newStatus MemberStatus
 }
 
+// changeScoreDecayHalfLife is how long it takes an elevated
+// changeScore to decay back halfway to zero once changes stop
+// arriving, so a past flap storm doesn't widen the coalescing window
+// indefinitely.
+const changeScoreDecayHalfLife = 10 * time.Second
+
+// decayChangeScore halves s.changeScore for every half-life elapsed
+// since the last decay, and is applied once per coalesceUpdates pass.
+func (s *Serf) decayChangeScore() {
+	now := time.Now()
+	if s.lastChangeDecay.IsZero() {
+		s.lastChangeDecay = now
+		return
+	}
+
+	elapsed := now.Sub(s.lastChangeDecay)
+	s.lastChangeDecay = now
+
+	halfLives := float64(elapsed) / float64(changeScoreDecayHalfLife)
+	s.changeScore *= math.Pow(0.5, halfLives)
+}
+
+// quiescentWindow scales MinQuiescentTime by the current changeScore,
+// widening the coalescing window under a flap storm so it doesn't
+// fire too eagerly, while clamping at MaxCoalesceTime so it never
+// waits longer than before.
+func (s *Serf) quiescentWindow() time.Duration {
+	scaled := time.Duration(float64(s.conf.MinQuiescentTime) * (1 + s.changeScore))
+	if scaled > s.conf.MaxCoalesceTime {
+		return s.conf.MaxCoalesceTime
+	}
+	return scaled
+}
+
 // changeHandler is a long running routine to coalesce updates,
 // and apply a partition detection heuristic
 func (s *Serf) changeHandler() {
 	// Run until indicated otherwise
-	for s.coalesceUpdates() {
+	for {
+		start := time.Now()
+		cont := s.coalesceUpdates()
+		metrics.AddSampleWithLabels([]string{"serf", "coalesce", "duration"},
+			float32(time.Since(start).Seconds()), s.conf.MetricLabels)
+
+		if !cont {
+			return
+		}
 	}
 }
 
@@ -25,12 +70,26 @@ func (s *Serf) changeHandler() {
 func (s *Serf) coalesceUpdates() bool {
 	initialStatus := make(map[*Member]MemberStatus)
 	endStatus := make(map[*Member]MemberStatus)
+	var userEvents []userEvent
 	var coalesceDone <-chan time.Time
 	var quiescent <-chan time.Time
 
+	s.decayChangeScore()
+
 OUTER:
 	for {
 		select {
+		case ev := <-s.eventCh:
+			userEvents = append(userEvents, ev)
+
+			// Setup an end timer if none exists
+			if coalesceDone == nil {
+				coalesceDone = time.After(s.conf.MaxCoalesceTime)
+			}
+
+			// Setup a new quiescent timer
+			quiescent = time.After(s.quiescentWindow())
+
 		case c := <-s.changeCh:
 			// Mark the initial and end status of the member
 			if _, ok := initialStatus[c.member]; !ok {
@@ -38,13 +97,22 @@ OUTER:
 			}
 			endStatus[c.member] = c.newStatus
 
+			// Persist the change so a restart can recover this state
+			if s.snapshotter != nil {
+				s.snapshotter.ingest(c)
+			}
+
+			// A burst of changes widens the coalescing window so a
+			// flap storm doesn't deliver many small delegate batches
+			s.changeScore++
+
 			// Setup an end timer if none exists
 			if coalesceDone == nil {
 				coalesceDone = time.After(s.conf.MaxCoalesceTime)
 			}
 
 			// Setup a new quiescent timer
-			quiescent = time.After(s.conf.MinQuiescentTime)
+			quiescent = time.After(s.quiescentWindow())
 
 		case <-coalesceDone:
 			break OUTER
@@ -56,17 +124,28 @@ OUTER:
 	}
 
 	// Fire any relevant events
-	s.invokeDelegate(initialStatus, endStatus)
+	s.publishMemberEvents(initialStatus, endStatus)
+
+	for _, ev := range userEvents {
+		s.publish(UserEvent{LTime: ev.LTime, Name: ev.Name, Payload: ev.Payload})
+	}
+
+	// Sample the resulting member counts once per coalesce cycle,
+	// rather than on every individual transition
+	s.updateMemberGauges(initialStatus, endStatus)
+	s.emitMemberGauges()
+
 	return true
 }
 
 // partitionedNodes into various groups based on their start and end states
-func partitionEvents(initial, end map[*Member]MemberStatus) (joined, left, failed, partitioned []*Member) {
+func (s *Serf) partitionEvents(initial, end map[*Member]MemberStatus) (joined, left, failed, partitioned []*Member) {
 	for member, endState := range end {
 		initState := initial[member]
 
 		// If a node is flapping, ignore it
 		if endState == initState {
+			s.emitMemberCounter("flap")
 			continue
 		}
 
@@ -84,30 +163,24 @@ func partitionEvents(initial, end map[*Member]MemberStatus) (joined, left, faile
 	return
 }
 
-// invokeDelegate is called to invoke the various delegate events
-// after the updates have been coalesced
-func (s *Serf) invokeDelegate(initial, end map[*Member]MemberStatus) {
-	// Bail if no delegate
-	d := s.conf.Delegate
-	if d == nil {
-		return
-	}
-
+// publishMemberEvents is called to publish the various membership
+// events after the updates have been coalesced.
+func (s *Serf) publishMemberEvents(initial, end map[*Member]MemberStatus) {
 	// Partition the nodes
-	joined, left, failed, partitioned := partitionEvents(initial, end)
+	joined, left, failed, partitioned := s.partitionEvents(initial, end)
 
-	// Invoke appropriate callbacks
+	// Publish the relevant events
 	if len(joined) > 0 {
-		d.MembersJoined(joined)
+		s.publish(MemberEvent{Type: EventMemberJoin, Members: joined})
 	}
 	if len(left) > 0 {
-		d.MembersLeft(left)
+		s.publish(MemberEvent{Type: EventMemberLeave, Members: left})
 	}
 	if len(failed) > 0 {
-		d.MembersFailed(failed)
+		s.publish(MemberEvent{Type: EventMemberFail, Members: failed})
 	}
 	if len(partitioned) > 0 {
-		d.MembersPartitioned(partitioned)
+		s.publish(MemberEvent{Type: EventMemberPartition, Members: partitioned})
 	}
 }
 
@@ -123,17 +196,29 @@ func (s *Serf) NotifyJoin(n *memberlist.Node) {
 		mem = &Member{
 			Name:   n.Name,
 			Addr:   n.Addr,
+			Port:   n.Port,
 			Role:   string(n.Meta),
 			Status: StatusAlive,
 		}
 		s.members[n.Name] = mem
 	} else {
 		oldStatus = mem.Status
+		mem.Addr = n.Addr
+		mem.Port = n.Port
 		mem.Status = StatusAlive
 	}
 
+	// NotifyJoin reflects memberlist's own physical reachability
+	// probe, not a gossiped claim, so it carries no incarnation of
+	// its own and never needs to be rejected as stale; the member's
+	// Incarnation is only ever advanced via alive/refutation gossip.
+
 	// Notify about change
 	s.changeCh <- statusChange{mem, oldStatus, StatusAlive}
+	s.emitMemberCounter("join")
+
+	// Clear any accumulated suspicion now that the node is reachable
+	s.clearSuspicion(mem)
 
 	// Check if node was previously in a failed state
 	if oldStatus != StatusFailed && oldStatus != StatusPartitioned {
@@ -163,8 +248,29 @@ func (s *Serf) NotifyLeave(n *memberlist.Node) {
 	oldStatus := mem.Status
 	switch mem.Status {
 	case StatusAlive:
-		mem.Status = StatusFailed
-		s.failedMembers = append(s.failedMembers, &oldMember{member: mem, time: time.Now()})
+		// If we are being marked failed, this is almost always a
+		// partitioned peer gossiping about us; refute it rather than
+		// accepting a failure claim about ourselves.
+		if mem == s.localNode {
+			s.refute()
+			return
+		}
+
+		// Don't fail the member outright on a single local detection;
+		// raise it to suspect and let the gossiped suspicion
+		// corroborate (or refute) the claim before escalating. A
+		// timer guarantees escalation even if too few peers exist to
+		// ever reach SuspicionMult distinct confirmations.
+		mem.Status = StatusSuspect
+		s.changeCh <- statusChange{mem, oldStatus, StatusSuspect}
+		s.startSuspicionTimer(mem)
+
+		if s.confirmSuspect(mem, s.conf.NodeName) >= s.suspicionMult() {
+			s.escalateToFailed(mem)
+		} else {
+			s.broadcastSuspicion(mem)
+		}
+		return
 
 	case StatusLeaving:
 		mem.Status = StatusLeft
@@ -173,13 +279,15 @@ func (s *Serf) NotifyLeave(n *memberlist.Node) {
 
 	// Check if we should notify about a change
 	s.changeCh <- statusChange{mem, oldStatus, mem.Status}
-
-	// Suspect a partition on failure
-	if mem.Status == StatusFailed {
-		s.suspectPartition(mem)
+	if mem.Status == StatusLeft {
+		s.emitMemberCounter("leave")
 	}
 }
 
+// NotifyUpdate is fired when memberlist detects a node has updated
+// its metadata. Serf does not currently act on metadata updates.
+func (s *Serf) NotifyUpdate(n *memberlist.Node) {}
+
 // intendLeave is invoked when we get a message indicating
 // an intention to leave. Returns true if we should re-broadcast
 func (s *Serf) intendLeave(l *leave) bool {
@@ -192,6 +300,12 @@ func (s *Serf) intendLeave(l *leave) bool {
 		return false // unknown, don't rebroadcast
 	}
 
+	// Ignore stale intentions that predate the member's current
+	// incarnation; a newer incarnation already supersedes this one.
+	if l.Incarnation < mem.Incarnation {
+		return false
+	}
+
 	// If the node is currently alive, then mark as a pending leave
 	// and re-broadcast
 	if mem.Status == StatusAlive {
@@ -230,6 +344,12 @@ func (s *Serf) forceRemove(r *remove) bool {
 		return false
 	}
 
+	// Ignore stale removals that predate the member's current
+	// incarnation; a newer incarnation already supersedes this one.
+	if r.Incarnation < mem.Incarnation {
+		return false
+	}
+
 	// If the node is alive, or has left, do nothing
 	if mem.Status == StatusAlive || mem.Status == StatusLeaving || mem.Status == StatusLeft {
 		return false
@@ -237,6 +357,7 @@ func (s *Serf) forceRemove(r *remove) bool {
 
 	// Update the status to Left
 	mem.Status = StatusLeft
+	s.emitMemberCounter("leave")
 
 	// Remove from failed list
 	s.failedMembers = removeOldMember(s.failedMembers, mem)
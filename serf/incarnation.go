@@ -0,0 +1,65 @@
+package serf
+
+import "log"
+
+// alive is the message broadcast by a member to refute a failure
+// claim made about it, carrying the bumped incarnation that
+// supersedes the claim at every peer that receives it.
+type alive struct {
+	Node        string
+	Incarnation uint32
+}
+
+// refute is called when NotifyLeave discovers that the member being
+// marked down is the local node itself, meaning a partitioned peer
+// gossiped a false failure. It bumps the local incarnation, forces
+// the status back to StatusAlive, and broadcasts the new incarnation
+// so it supersedes the failure claim cluster-wide.
+func (s *Serf) refute() {
+	s.localNode.Incarnation++
+	s.localNode.Status = StatusAlive
+
+	a := alive{Node: s.localNode.Name, Incarnation: s.localNode.Incarnation}
+	if err := s.queueBroadcast(messageAliveType, &a); err != nil {
+		log.Printf("[ERR] serf: failed to broadcast refutation: %v", err)
+	}
+}
+
+// handleAlive applies an incoming alive/refutation message, ignoring
+// it if it carries a stale incarnation for the member in question.
+func (s *Serf) handleAlive(a *alive) bool {
+	s.memberLock.Lock()
+	defer s.memberLock.Unlock()
+
+	mem, ok := s.members[a.Node]
+	if !ok {
+		return false
+	}
+
+	if a.Incarnation < mem.Incarnation {
+		// Stale, already-superseded claim; ignore it.
+		return false
+	}
+
+	// A claim at the member's current incarnation cannot resurrect a
+	// failed/left member; only a strictly newer incarnation, which can
+	// only have come from the member itself refuting the claim, may do
+	// that. Otherwise this is a replay of the same alive message that
+	// already got it to its current (non-alive) status.
+	if a.Incarnation == mem.Incarnation && mem.Status != StatusAlive {
+		return false
+	}
+
+	oldStatus := mem.Status
+	mem.Incarnation = a.Incarnation
+	mem.Status = StatusAlive
+
+	if oldStatus != StatusAlive {
+		s.changeCh <- statusChange{mem, oldStatus, StatusAlive}
+		s.failedMembers = removeOldMember(s.failedMembers, mem)
+		s.leftMembers = removeOldMember(s.leftMembers, mem)
+		s.unsuspectPartition(mem)
+	}
+
+	return true
+}
@@ -0,0 +1,63 @@
+package serf
+
+import "testing"
+
+// TestHandleAliveIgnoresStaleIncarnation verifies that an alive
+// message carrying an incarnation older than the member's current one
+// is dropped.
+func TestHandleAliveIgnoresStaleIncarnation(t *testing.T) {
+	s := newTestSerf(&Config{NodeName: "local"})
+
+	mem := &Member{Name: "m1", Status: StatusFailed, Incarnation: 5}
+	s.members[mem.Name] = mem
+
+	if s.handleAlive(&alive{Node: mem.Name, Incarnation: 4}) {
+		t.Fatal("expected stale alive message to be ignored")
+	}
+	if mem.Status != StatusFailed {
+		t.Fatalf("expected member to remain failed, got %v", mem.Status)
+	}
+}
+
+// TestHandleAliveEqualIncarnationDoesNotResurrect verifies that an
+// alive message at the member's already-recorded incarnation cannot
+// resurrect it from a non-alive status; only a strictly newer
+// incarnation, which can only come from the member refuting the
+// claim itself, may do that.
+func TestHandleAliveEqualIncarnationDoesNotResurrect(t *testing.T) {
+	s := newTestSerf(&Config{NodeName: "local"})
+
+	mem := &Member{Name: "m1", Status: StatusFailed, Incarnation: 5}
+	s.members[mem.Name] = mem
+
+	if s.handleAlive(&alive{Node: mem.Name, Incarnation: 5}) {
+		t.Fatal("expected replayed alive message at the same incarnation to be rejected")
+	}
+	if mem.Status != StatusFailed {
+		t.Fatalf("expected member to remain failed, got %v", mem.Status)
+	}
+}
+
+// TestHandleAliveNewerIncarnationSupersedesFailure verifies that a
+// refutation carrying a strictly newer incarnation resurrects a
+// member out of StatusFailed, superseding the earlier failure claim.
+func TestHandleAliveNewerIncarnationSupersedesFailure(t *testing.T) {
+	s := newTestSerf(&Config{NodeName: "local"})
+
+	mem := &Member{Name: "m1", Status: StatusFailed, Incarnation: 5}
+	s.members[mem.Name] = mem
+	s.failedMembers = append(s.failedMembers, &oldMember{member: mem})
+
+	if !s.handleAlive(&alive{Node: mem.Name, Incarnation: 6}) {
+		t.Fatal("expected refutation at a newer incarnation to be accepted")
+	}
+	if mem.Status != StatusAlive {
+		t.Fatalf("expected member to be alive, got %v", mem.Status)
+	}
+	if mem.Incarnation != 6 {
+		t.Fatalf("expected incarnation to be updated to 6, got %d", mem.Incarnation)
+	}
+	if len(s.failedMembers) != 0 {
+		t.Fatalf("expected member to be removed from failedMembers, got %d entries", len(s.failedMembers))
+	}
+}
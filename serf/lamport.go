@@ -0,0 +1,39 @@
+package serf
+
+import "sync/atomic"
+
+// LamportTime is an integer that orders events within the cluster
+// according to a Lamport logical clock, rather than wall-clock time.
+type LamportTime uint64
+
+// LamportClock is a thread-safe Lamport logical clock, incremented on
+// every send and advanced to stay causally consistent on receive.
+type LamportClock struct {
+	counter uint64
+}
+
+// Time returns the current value of the clock.
+func (l *LamportClock) Time() LamportTime {
+	return LamportTime(atomic.LoadUint64(&l.counter))
+}
+
+// Increment advances the clock for an event about to be sent and
+// returns the new value to attach to it.
+func (l *LamportClock) Increment() LamportTime {
+	return LamportTime(atomic.AddUint64(&l.counter, 1))
+}
+
+// Witness is called on receipt of an event carrying a foreign Lamport
+// time, advancing the local clock past it: LTime = max(LTime, v)+1.
+func (l *LamportClock) Witness(v LamportTime) {
+	for {
+		cur := atomic.LoadUint64(&l.counter)
+		other := uint64(v)
+		if other < cur {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&l.counter, cur, other+1) {
+			return
+		}
+	}
+}
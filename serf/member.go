@@ -0,0 +1,53 @@
+package serf
+
+import (
+	"net"
+	"time"
+)
+
+// MemberStatus is the state that a member is in.
+type MemberStatus int
+
+const (
+	StatusNone MemberStatus = iota
+	StatusAlive
+	StatusLeaving
+	StatusLeft
+	StatusSuspect
+	StatusFailed
+	StatusPartitioned
+)
+
+// Member represents a single member of the Serf cluster.
+type Member struct {
+	Name   string
+	Addr   net.IP
+	Port   uint16
+	Role   string
+	Status MemberStatus
+
+	// Incarnation distinguishes successive "alive" claims about this
+	// member. It only ever increases, and is bumped by the member
+	// itself to refute a stale failure claim made about it by peers
+	// that were on the wrong side of a partition.
+	Incarnation uint32
+}
+
+// oldMember is used to track a member that has left or failed, along
+// with the time of the transition, so that it can later be reaped.
+type oldMember struct {
+	member *Member
+	time   time.Time
+}
+
+// removeOldMember is used to remove a member from a list of old
+// members if it is present.
+func removeOldMember(old []*oldMember, mem *Member) []*oldMember {
+	for i, m := range old {
+		if m.member == mem {
+			old[i], old[len(old)-1] = old[len(old)-1], old[i]
+			return old[:len(old)-1]
+		}
+	}
+	return old
+}
@@ -0,0 +1,65 @@
+package serf
+
+import "github.com/armon/go-metrics"
+
+// emitMemberCounter increments serf.member.<event>, tagged with the
+// configured MetricLabels, so operators can track the rate of each
+// kind of membership transition.
+func (s *Serf) emitMemberCounter(event string) {
+	metrics.IncrCounterWithLabels([]string{"serf", "member", event}, 1, s.conf.MetricLabels)
+}
+
+// emitSubscriberDropped increments serf.subscriber.dropped, tagged
+// with the configured MetricLabels, so operators can detect a
+// subscriber that is falling behind and losing events.
+func (s *Serf) emitSubscriberDropped() {
+	metrics.IncrCounterWithLabels([]string{"serf", "subscriber", "dropped"}, 1, s.conf.MetricLabels)
+}
+
+// updateMemberGauges folds a coalesce cycle's status transitions,
+// already collected in the initial/end maps, into the running
+// per-status member counts. This is deliberately guarded by its own
+// gaugeLock rather than memberLock: producers send to changeCh while
+// holding memberLock, so re-acquiring it here from the consumer side
+// of that same channel could deadlock if the channel ever backs up.
+func (s *Serf) updateMemberGauges(initial, end map[*Member]MemberStatus) {
+	s.gaugeLock.Lock()
+	defer s.gaugeLock.Unlock()
+
+	for member, endState := range end {
+		initState := initial[member]
+		if endState == initState {
+			continue
+		}
+		if initState != StatusNone {
+			s.memberCounts[initState]--
+		}
+		s.memberCounts[endState]++
+	}
+}
+
+// decrementGauge removes one member in the given status from the
+// running counts, used when a tombstoned member is reaped entirely
+// out of s.members rather than transitioning to another status.
+func (s *Serf) decrementGauge(status MemberStatus) {
+	s.gaugeLock.Lock()
+	defer s.gaugeLock.Unlock()
+	s.memberCounts[status]--
+}
+
+// emitMemberGauges samples the current size of each membership
+// bucket from the running counts, intended to be called once per
+// coalesce cycle rather than per individual transition.
+func (s *Serf) emitMemberGauges() {
+	s.gaugeLock.Lock()
+	alive := s.memberCounts[StatusAlive]
+	failed := s.memberCounts[StatusFailed] + s.memberCounts[StatusSuspect]
+	left := s.memberCounts[StatusLeaving] + s.memberCounts[StatusLeft]
+	partitioned := s.memberCounts[StatusPartitioned]
+	s.gaugeLock.Unlock()
+
+	metrics.SetGaugeWithLabels([]string{"serf", "members", "alive"}, float32(alive), s.conf.MetricLabels)
+	metrics.SetGaugeWithLabels([]string{"serf", "members", "failed"}, float32(failed), s.conf.MetricLabels)
+	metrics.SetGaugeWithLabels([]string{"serf", "members", "left"}, float32(left), s.conf.MetricLabels)
+	metrics.SetGaugeWithLabels([]string{"serf", "members", "partitioned"}, float32(partitioned), s.conf.MetricLabels)
+}
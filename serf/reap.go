@@ -0,0 +1,82 @@
+package serf
+
+import "time"
+
+// reap is a long running routine that periodically reaps tombstoned
+// members (those in failedMembers or leftMembers) once they have
+// been in that state longer than the configured timeout. This keeps
+// s.members from growing without bound as nodes come and go.
+func (s *Serf) reap() {
+	for {
+		select {
+		case <-time.After(s.conf.ReapInterval):
+			s.Reap()
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// Reap forces an immediate pass over the failed and left member
+// lists, removing any entries older than their respective timeouts.
+// It is normally invoked periodically by the reap goroutine, but is
+// exported so operators can trigger cleanup on demand.
+func (s *Serf) Reap() {
+	s.memberLock.Lock()
+	defer s.memberLock.Unlock()
+
+	now := time.Now()
+	s.failedMembers = s.reapOldMembers(s.failedMembers, now, s.conf.TombstoneTimeout)
+	s.leftMembers = s.reapOldMembers(s.leftMembers, now, s.conf.LeaveTombstoneTimeout)
+}
+
+// reapOldMembers removes old members whose time is older than the
+// given timeout, deleting them from s.members and notifying the
+// delegate. The caller must hold memberLock.
+func (s *Serf) reapOldMembers(old []*oldMember, now time.Time, timeout time.Duration) []*oldMember {
+	var reaped []*Member
+	n := len(old)
+	for i := 0; i < n; i++ {
+		m := old[i]
+		if now.Sub(m.time) <= timeout {
+			continue
+		}
+
+		delete(s.members, m.member.Name)
+		s.decrementGauge(m.member.Status)
+		reaped = append(reaped, m.member)
+
+		old[i], old[n-1] = old[n-1], old[i]
+		old = old[:n-1]
+		n--
+		i--
+	}
+
+	if len(reaped) > 0 {
+		s.publish(MemberEvent{Type: EventMemberReap, Members: reaped})
+	}
+
+	return old
+}
+
+// RemoveFailedNode forces a failed member to be removed from the
+// cluster immediately, without waiting for TombstoneTimeout to
+// elapse. This is useful for operators who know a node is never
+// coming back and want its state cleaned up right away.
+func (s *Serf) RemoveFailedNode(name string) error {
+	s.memberLock.Lock()
+	defer s.memberLock.Unlock()
+
+	mem, ok := s.members[name]
+	if !ok || mem.Status != StatusFailed {
+		return nil
+	}
+
+	s.failedMembers = removeOldMember(s.failedMembers, mem)
+	delete(s.members, name)
+	s.decrementGauge(mem.Status)
+
+	s.publish(MemberEvent{Type: EventMemberReap, Members: []*Member{mem}})
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package serf
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// Serf is a single node that is part of a single cluster that gets
+// events about joins/leaves/failures/etc. It is the primary interface
+// for starting and controlling a Serf instance.
+type Serf struct {
+	conf       *Config
+	memberlist *memberlist.Memberlist
+	broadcasts *memberlist.TransmitLimitedQueue
+
+	memberLock    sync.RWMutex
+	members       map[string]*Member
+	failedMembers []*oldMember
+	leftMembers   []*oldMember
+	localNode     *Member
+
+	partitionLock    sync.Mutex
+	partitionedNodes map[string]struct{}
+
+	// gaugeLock guards memberCounts, the running per-status member
+	// tally used to emit gauges without re-acquiring memberLock from
+	// changeHandler's goroutine.
+	gaugeLock    sync.Mutex
+	memberCounts map[MemberStatus]int
+
+	// confirmations tracks, per suspected member name, the set of
+	// distinct peers that have corroborated a failure claim. Guarded
+	// by memberLock since it is only ever touched alongside s.members.
+	confirmations map[string]map[string]struct{}
+
+	snapshotter *Snapshotter
+
+	eventClock   LamportClock
+	eventLock    sync.Mutex
+	recentEvents map[string][]LamportTime
+
+	subscriberLock sync.Mutex
+	subscribers    []*subscription
+
+	changeScore     float64
+	lastChangeDecay time.Time
+
+	changeCh   chan statusChange
+	eventCh    chan userEvent
+	shutdownCh chan struct{}
+	shutdown   bool
+	shutdownLock sync.Mutex
+}
+
+// leave is the message broadcast to signal a node's intent to leave.
+type leave struct {
+	Node        string
+	Incarnation uint32
+}
+
+// remove is the message broadcast to force-remove a failed node.
+type remove struct {
+	Node        string
+	Incarnation uint32
+}
+
+// Create creates a new Serf instance, starting all the background
+// goroutines necessary for a functioning Serf instance.
+func Create(conf *Config) (*Serf, error) {
+	s := &Serf{
+		conf:             conf,
+		members:          make(map[string]*Member),
+		partitionedNodes: make(map[string]struct{}),
+		changeCh:         make(chan statusChange, 1024),
+		eventCh:          make(chan userEvent, 1024),
+		shutdownCh:       make(chan struct{}),
+	}
+
+	s.localNode = &Member{Name: conf.NodeName, Status: StatusAlive}
+	s.members[conf.NodeName] = s.localNode
+	s.memberCounts = map[MemberStatus]int{StatusAlive: 1}
+
+	var previousPeers []string
+	if conf.SnapshotPath != "" {
+		peers, clean, err := replaySnapshot(conf.SnapshotPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay snapshot: %v", err)
+		}
+
+		// Only auto-rejoin if the previous incarnation didn't cleanly
+		// leave; a clean leave means the operator intentionally took
+		// this node out of the cluster, and it should come back up
+		// standalone rather than rejoining automatically.
+		if !clean {
+			previousPeers = peers
+		}
+
+		sn, err := newSnapshotter(conf.SnapshotPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open snapshot: %v", err)
+		}
+		s.snapshotter = sn
+	}
+
+	mlConf := memberlist.DefaultConfig()
+	mlConf.Name = conf.NodeName
+	mlConf.Delegate = s
+	mlConf.Events = s
+
+	ml, err := memberlist.Create(mlConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create memberlist: %v", err)
+	}
+	s.memberlist = ml
+
+	s.broadcasts = &memberlist.TransmitLimitedQueue{
+		NumNodes:       s.numMembers,
+		RetransmitMult: 3,
+	}
+
+	// memberlist.Create synchronously bootstraps by delivering its own
+	// NotifyJoin for the local node, which already looks up s.localNode
+	// in s.members, fills in its Addr/Port, and queues a statusChange
+	// onto changeCh. Once changeHandler starts draining that below,
+	// ingest covers the local node the same way it covers every peer;
+	// no separate snapshot write is needed here.
+
+	go s.changeHandler()
+	go s.reap()
+
+	if conf.Delegate != nil {
+		go s.delegateAdapter(conf.Delegate)
+	}
+
+	if len(previousPeers) > 0 {
+		go s.joinWithBackoff(previousPeers, s.shutdownCh)
+	}
+
+	return s, nil
+}
+
+// Join joins an existing Serf cluster by contacting the given set of
+// peer addresses. Returns the number of nodes successfully contacted.
+func (s *Serf) Join(addrs []string) (int, error) {
+	return s.memberlist.Join(addrs)
+}
+
+// Leave gracefully leaves the cluster, broadcasting an intent to
+// leave so other members can transition us to StatusLeft.
+func (s *Serf) Leave() error {
+	if err := s.memberlist.Leave(); err != nil {
+		return err
+	}
+	if s.snapshotter != nil {
+		s.snapshotter.recordLeave()
+	}
+	return nil
+}
+
+// Members returns a point-in-time snapshot of all known members.
+func (s *Serf) Members() []*Member {
+	s.memberLock.RLock()
+	defer s.memberLock.RUnlock()
+
+	members := make([]*Member, 0, len(s.members))
+	for _, m := range s.members {
+		members = append(members, m)
+	}
+	return members
+}
+
+// numMembers returns the current number of known members, used to
+// size the retransmit budget of the broadcast queue.
+func (s *Serf) numMembers() int {
+	s.memberLock.RLock()
+	defer s.memberLock.RUnlock()
+	return len(s.members)
+}
+
+// suspectPartition is invoked when a member fails, marking it as a
+// potential partition candidate rather than a clean failure.
+func (s *Serf) suspectPartition(mem *Member) {
+	s.partitionLock.Lock()
+	defer s.partitionLock.Unlock()
+	s.partitionedNodes[mem.Name] = struct{}{}
+	s.emitMemberCounter("partitioned")
+}
+
+// unsuspectPartition clears a member from partition suspicion.
+func (s *Serf) unsuspectPartition(mem *Member) {
+	s.partitionLock.Lock()
+	defer s.partitionLock.Unlock()
+	delete(s.partitionedNodes, mem.Name)
+}
+
+// Shutdown forcefully shuts down the Serf instance, stopping all
+// background goroutines immediately.
+func (s *Serf) Shutdown() error {
+	s.shutdownLock.Lock()
+	defer s.shutdownLock.Unlock()
+
+	if s.shutdown {
+		return nil
+	}
+	s.shutdown = true
+	close(s.shutdownCh)
+
+	if s.snapshotter != nil {
+		s.snapshotter.close()
+	}
+
+	return s.memberlist.Shutdown()
+}
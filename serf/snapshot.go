@@ -0,0 +1,212 @@
+package serf
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snapshotCompactThreshold is the size, in bytes, beyond which the
+// snapshot file is compacted by rewriting only the current live set.
+const snapshotCompactThreshold = 128 * 1024
+
+// snapshotJoinBackoffMax caps the exponential backoff used while
+// rejoining peers recovered from a replayed snapshot.
+const snapshotJoinBackoffMax = 60 * time.Second
+
+// Snapshotter is responsible for persisting every statusChange (and
+// the local node's own alive/leave transitions) to an append-only
+// file, so that a restarted node can recover its previous view of
+// the cluster without operator intervention.
+type Snapshotter struct {
+	path string
+
+	fhLock sync.Mutex
+	fh     *os.File
+	buf    *bufio.Writer
+	size   int64
+
+	lastAlive map[string]string // name -> addr, current live set
+}
+
+// newSnapshotter opens (or creates) the snapshot file at path and
+// returns a Snapshotter ready to ingest status changes.
+func newSnapshotter(path string) (*Snapshotter, error) {
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %v", err)
+	}
+
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, fmt.Errorf("failed to stat snapshot: %v", err)
+	}
+
+	return &Snapshotter{
+		path:      path,
+		fh:        fh,
+		buf:       bufio.NewWriter(fh),
+		size:      info.Size(),
+		lastAlive: make(map[string]string),
+	}, nil
+}
+
+// replaySnapshot reads an existing snapshot file and returns the
+// addresses of previously known alive peers, along with whether the
+// previous incarnation recorded a clean leave.
+func replaySnapshot(path string) (peers []string, clean bool, err error) {
+	fh, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	defer fh.Close()
+
+	alive := make(map[string]string)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "leave":
+			clean = true
+		case "alive":
+			if len(fields) == 3 {
+				alive[fields[1]] = fields[2]
+			}
+		case "not-alive":
+			if len(fields) == 2 {
+				delete(alive, fields[1])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, true, err
+	}
+
+	for _, addr := range alive {
+		peers = append(peers, addr)
+	}
+	return peers, clean, nil
+}
+
+// close flushes any buffered writes and closes the snapshot file.
+func (sn *Snapshotter) close() {
+	sn.fhLock.Lock()
+	defer sn.fhLock.Unlock()
+	sn.buf.Flush()
+	sn.fh.Close()
+}
+
+// ingest records a single status change to the snapshot file and
+// triggers a compaction if the file has grown beyond the threshold.
+func (sn *Snapshotter) ingest(c statusChange) {
+	sn.fhLock.Lock()
+	defer sn.fhLock.Unlock()
+
+	switch c.newStatus {
+	case StatusAlive:
+		hostPort := net.JoinHostPort(c.member.Addr.String(), strconv.Itoa(int(c.member.Port)))
+		sn.lastAlive[c.member.Name] = hostPort
+		sn.writeLine(fmt.Sprintf("alive %s %s", c.member.Name, hostPort))
+	case StatusLeft, StatusFailed:
+		delete(sn.lastAlive, c.member.Name)
+		sn.writeLine(fmt.Sprintf("not-alive %s", c.member.Name))
+	}
+
+	if sn.size > snapshotCompactThreshold {
+		sn.compact()
+	}
+}
+
+// recordLeave marks that this node cleanly left the cluster, so a
+// future restart will not resurrect it as StatusLeaving.
+func (sn *Snapshotter) recordLeave() {
+	sn.fhLock.Lock()
+	defer sn.fhLock.Unlock()
+	sn.writeLine("leave")
+}
+
+// writeLine appends a line to the snapshot buffer. The caller must
+// hold fhLock.
+func (sn *Snapshotter) writeLine(line string) {
+	n, err := sn.buf.WriteString(line + "\n")
+	if err != nil {
+		log.Printf("[ERR] serf: failed to write snapshot: %v", err)
+		return
+	}
+	sn.buf.Flush()
+	sn.size += int64(n)
+}
+
+// compact rewrites the snapshot file to contain only the current
+// live member set, discarding the append-only history that preceded
+// it. The caller must hold fhLock.
+func (sn *Snapshotter) compact() {
+	tmpPath := sn.path + ".compact"
+	fh, err := os.Create(tmpPath)
+	if err != nil {
+		log.Printf("[ERR] serf: failed to compact snapshot: %v", err)
+		return
+	}
+
+	w := bufio.NewWriter(fh)
+	var size int64
+	for name, addr := range sn.lastAlive {
+		n, _ := w.WriteString(fmt.Sprintf("alive %s %s\n", name, addr))
+		size += int64(n)
+	}
+	w.Flush()
+	fh.Close()
+
+	sn.fh.Close()
+	if err := os.Rename(tmpPath, sn.path); err != nil {
+		log.Printf("[ERR] serf: failed to replace snapshot: %v", err)
+		return
+	}
+
+	fh, err = os.OpenFile(sn.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("[ERR] serf: failed to reopen snapshot: %v", err)
+		return
+	}
+	sn.fh = fh
+	sn.buf = bufio.NewWriter(fh)
+	sn.size = size
+}
+
+// joinWithBackoff attempts to rejoin the given peer addresses,
+// recovered from a replayed snapshot, retrying with exponential
+// backoff until at least one peer is contacted or shutdownCh closes.
+func (s *Serf) joinWithBackoff(peers []string, shutdownCh <-chan struct{}) {
+	backoff := time.Second
+	for {
+		if n, err := s.Join(peers); err != nil || n == 0 {
+			select {
+			case <-time.After(backoff):
+			case <-shutdownCh:
+				return
+			}
+
+			backoff *= 2
+			if backoff > snapshotJoinBackoffMax {
+				backoff = snapshotJoinBackoffMax
+			}
+			continue
+		}
+		return
+	}
+}
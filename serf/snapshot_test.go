@@ -0,0 +1,74 @@
+package serf
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// TestSnapshotRoundTrip verifies that a snapshot written via ingest
+// can be replayed to recover the live peer set as host:port pairs.
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+
+	sn, err := newSnapshotter(path)
+	if err != nil {
+		t.Fatalf("newSnapshotter: %v", err)
+	}
+
+	alive := &Member{Name: "m1", Addr: net.ParseIP("10.0.0.1"), Port: 7946}
+	gone := &Member{Name: "m2", Addr: net.ParseIP("10.0.0.2"), Port: 7946}
+
+	sn.ingest(statusChange{member: alive, oldStatus: StatusNone, newStatus: StatusAlive})
+	sn.ingest(statusChange{member: gone, oldStatus: StatusNone, newStatus: StatusAlive})
+	sn.ingest(statusChange{member: gone, oldStatus: StatusAlive, newStatus: StatusFailed})
+	sn.close()
+
+	peers, clean, err := replaySnapshot(path)
+	if err != nil {
+		t.Fatalf("replaySnapshot: %v", err)
+	}
+	if clean {
+		t.Fatal("expected clean to be false without a recorded leave")
+	}
+	if len(peers) != 1 || peers[0] != "10.0.0.1:7946" {
+		t.Fatalf("expected exactly [10.0.0.1:7946], got %v", peers)
+	}
+}
+
+// TestSnapshotRoundTripAfterLeave verifies that a recorded leave is
+// reflected back as clean on replay.
+func TestSnapshotRoundTripAfterLeave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+
+	sn, err := newSnapshotter(path)
+	if err != nil {
+		t.Fatalf("newSnapshotter: %v", err)
+	}
+
+	alive := &Member{Name: "m1", Addr: net.ParseIP("10.0.0.1"), Port: 7946}
+	sn.ingest(statusChange{member: alive, oldStatus: StatusNone, newStatus: StatusAlive})
+	sn.recordLeave()
+	sn.close()
+
+	_, clean, err := replaySnapshot(path)
+	if err != nil {
+		t.Fatalf("replaySnapshot: %v", err)
+	}
+	if !clean {
+		t.Fatal("expected clean to be true after a recorded leave")
+	}
+}
+
+// TestReplaySnapshotMissingFile verifies that replaying a
+// nonexistent snapshot is treated as a clean, peerless start rather
+// than an error.
+func TestReplaySnapshotMissingFile(t *testing.T) {
+	peers, clean, err := replaySnapshot(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("replaySnapshot: %v", err)
+	}
+	if !clean || len(peers) != 0 {
+		t.Fatalf("expected clean start with no peers, got clean=%v peers=%v", clean, peers)
+	}
+}
@@ -0,0 +1,145 @@
+package serf
+
+// EventType identifies the kind of Event delivered to a subscriber.
+type EventType int
+
+const (
+	EventMemberJoin EventType = iota
+	EventMemberLeave
+	EventMemberFail
+	EventMemberPartition
+	EventMemberReap
+	EventUser
+)
+
+// Event is the sum type delivered to subscribers. MemberEvent covers
+// every membership transition; UserEvent covers application payloads
+// sent via Serf.UserEvent.
+type Event interface {
+	EventType() EventType
+}
+
+// MemberEvent is fired whenever one or more members transition
+// together into the same end state during a single coalescing pass.
+type MemberEvent struct {
+	Type    EventType
+	Members []*Member
+}
+
+func (m MemberEvent) EventType() EventType { return m.Type }
+
+// UserEvent is fired when an application-defined payload is received,
+// either locally or from a peer, via Serf.UserEvent.
+type UserEvent struct {
+	LTime   LamportTime
+	Name    string
+	Payload []byte
+}
+
+func (u UserEvent) EventType() EventType { return EventUser }
+
+// subscriberBuffer bounds how many undelivered events a subscriber's
+// channel may hold before further events are dropped for it rather
+// than blocking the publisher.
+const subscriberBuffer = 1024
+
+// subscription is a single consumer registered via Serf.Subscribe.
+type subscription struct {
+	ch      chan Event
+	dropped uint64
+}
+
+// CancelFunc unregisters a subscription created by Serf.Subscribe.
+type CancelFunc func()
+
+// subscriberBufferSize returns the configured subscriber channel
+// capacity, or subscriberBuffer if unset.
+func (s *Serf) subscriberBufferSize() int {
+	if s.conf.SubscriberBufferSize > 0 {
+		return s.conf.SubscriberBufferSize
+	}
+	return subscriberBuffer
+}
+
+// Subscribe registers a new consumer of cluster events and returns a
+// channel of events along with a function to cancel the subscription.
+// Each subscriber gets its own buffered channel; a subscriber that
+// falls behind has events dropped for it rather than blocking
+// changeHandler for everyone else.
+func (s *Serf) Subscribe() (<-chan Event, CancelFunc) {
+	sub := &subscription{ch: make(chan Event, s.subscriberBufferSize())}
+
+	s.subscriberLock.Lock()
+	s.subscribers = append(s.subscribers, sub)
+	s.subscriberLock.Unlock()
+
+	cancel := func() {
+		s.subscriberLock.Lock()
+		defer s.subscriberLock.Unlock()
+
+		for i, sb := range s.subscribers {
+			if sb == sub {
+				s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+				close(sub.ch)
+				return
+			}
+		}
+	}
+
+	return sub.ch, cancel
+}
+
+// publish fans ev out to every current subscriber. A subscriber whose
+// channel is full has the event dropped for it, tracked in its
+// dropped counter, rather than stalling the rest of the cluster.
+func (s *Serf) publish(ev Event) {
+	s.subscriberLock.Lock()
+	defer s.subscriberLock.Unlock()
+
+	for _, sub := range s.subscribers {
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.dropped++
+			s.emitSubscriberDropped()
+		}
+	}
+}
+
+// delegateAdapter subscribes on behalf of a legacy Delegate and
+// translates every Event back into the corresponding Delegate method,
+// so existing Delegate implementations keep working unmodified.
+func (s *Serf) delegateAdapter(d Delegate) {
+	ch, cancel := s.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			switch e := ev.(type) {
+			case MemberEvent:
+				switch e.Type {
+				case EventMemberJoin:
+					d.MembersJoined(e.Members)
+				case EventMemberLeave:
+					d.MembersLeft(e.Members)
+				case EventMemberFail:
+					d.MembersFailed(e.Members)
+				case EventMemberPartition:
+					d.MembersPartitioned(e.Members)
+				case EventMemberReap:
+					d.MembersReaped(e.Members)
+				}
+			case UserEvent:
+				d.UserEvent(e.Name, uint64(e.LTime), e.Payload)
+			}
+
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package serf
+
+import (
+	"log"
+	"time"
+)
+
+// defaultSuspicionMult is used when Config.SuspicionMult is left at
+// its zero value.
+const defaultSuspicionMult = 3
+
+// defaultSuspicionTimeout is used when Config.SuspicionTimeout is left
+// at its zero value.
+const defaultSuspicionTimeout = 5 * time.Second
+
+// suspect is the message broadcast by a node that independently
+// detects a peer may have failed. Peers accumulate these by distinct
+// reporter to decide when a claim is corroborated enough to escalate.
+type suspect struct {
+	Node        string
+	From        string
+	Incarnation uint32
+}
+
+// confirmSuspect records that From has corroborated a failure claim
+// about mem, and returns the number of distinct reporters seen so far
+// for mem's current incarnation. The caller must hold memberLock.
+func (s *Serf) confirmSuspect(mem *Member, from string) int {
+	if s.confirmations == nil {
+		s.confirmations = make(map[string]map[string]struct{})
+	}
+
+	reporters, ok := s.confirmations[mem.Name]
+	if !ok {
+		reporters = make(map[string]struct{})
+		s.confirmations[mem.Name] = reporters
+	}
+	reporters[from] = struct{}{}
+	return len(reporters)
+}
+
+// clearSuspicion discards any accumulated confirmations for mem, used
+// once it either resolves back to alive or is escalated to failed.
+func (s *Serf) clearSuspicion(mem *Member) {
+	delete(s.confirmations, mem.Name)
+}
+
+// suspicionMult returns the configured confirmation threshold, or the
+// default if unset.
+func (s *Serf) suspicionMult() int {
+	if s.conf.SuspicionMult > 0 {
+		return s.conf.SuspicionMult
+	}
+	return defaultSuspicionMult
+}
+
+// suspicionTimeout returns the configured suspicion timeout, or the
+// default if unset.
+func (s *Serf) suspicionTimeout() time.Duration {
+	if s.conf.SuspicionTimeout > 0 {
+		return s.conf.SuspicionTimeout
+	}
+	return defaultSuspicionTimeout
+}
+
+// startSuspicionTimer arms a timeout that escalates mem to
+// StatusFailed if it is still suspect once it fires. This guarantees
+// forward progress even in clusters too small to ever gather
+// SuspicionMult distinct corroborating reports, where otherwise a
+// dead member would be stuck as StatusSuspect indefinitely. The
+// caller must hold memberLock.
+func (s *Serf) startSuspicionTimer(mem *Member) {
+	time.AfterFunc(s.suspicionTimeout(), func() {
+		s.memberLock.Lock()
+		defer s.memberLock.Unlock()
+
+		if mem.Status == StatusSuspect {
+			s.escalateToFailed(mem)
+		}
+	})
+}
+
+// handleSuspect applies an incoming suspect message: it adds the
+// reporter's corroboration and, once enough distinct members have
+// corroborated the claim, escalates the member to StatusFailed.
+// Returns true if the message should be re-broadcast.
+func (s *Serf) handleSuspect(sp *suspect) bool {
+	s.memberLock.Lock()
+	defer s.memberLock.Unlock()
+
+	mem, ok := s.members[sp.Node]
+	if !ok || sp.Incarnation < mem.Incarnation {
+		return false
+	}
+
+	if mem.Status != StatusAlive && mem.Status != StatusSuspect {
+		return false
+	}
+
+	oldStatus := mem.Status
+	mem.Status = StatusSuspect
+
+	if oldStatus == StatusAlive {
+		s.changeCh <- statusChange{mem, oldStatus, StatusSuspect}
+		s.startSuspicionTimer(mem)
+	}
+
+	if s.confirmSuspect(mem, sp.From) >= s.suspicionMult() {
+		s.escalateToFailed(mem)
+	}
+
+	return true
+}
+
+// escalateToFailed transitions a suspected member to StatusFailed,
+// either because enough distinct peers corroborated the claim against
+// it or because its suspicion timer expired with no resolution. The
+// caller must hold memberLock.
+func (s *Serf) escalateToFailed(mem *Member) {
+	oldStatus := mem.Status
+	mem.Status = StatusFailed
+	s.failedMembers = append(s.failedMembers, &oldMember{member: mem, time: time.Now()})
+	s.clearSuspicion(mem)
+	s.emitMemberCounter("failed")
+
+	s.changeCh <- statusChange{mem, oldStatus, StatusFailed}
+	s.suspectPartition(mem)
+}
+
+// broadcastSuspicion gossips a suspect message reporting that the
+// local node has independently observed mem as unreachable.
+func (s *Serf) broadcastSuspicion(mem *Member) {
+	sp := suspect{Node: mem.Name, From: s.conf.NodeName, Incarnation: mem.Incarnation}
+	if err := s.queueBroadcast(messageSuspectType, &sp); err != nil {
+		log.Printf("[ERR] serf: failed to broadcast suspicion: %v", err)
+	}
+}
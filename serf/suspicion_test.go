@@ -0,0 +1,71 @@
+package serf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// newTestSerf builds a *Serf with just the state the suspicion/
+// incarnation logic touches, without starting real memberlist
+// networking or background goroutines.
+func newTestSerf(conf *Config) *Serf {
+	s := &Serf{
+		conf:             conf,
+		members:          make(map[string]*Member),
+		partitionedNodes: make(map[string]struct{}),
+		memberCounts:     make(map[MemberStatus]int),
+		changeCh:         make(chan statusChange, 1024),
+	}
+	s.broadcasts = &memberlist.TransmitLimitedQueue{
+		NumNodes:       func() int { return len(s.members) },
+		RetransmitMult: 3,
+	}
+	return s
+}
+
+// TestHandleSuspectEscalatesAtConfiguredThreshold verifies that a
+// member is only escalated to StatusFailed once it has been
+// corroborated by exactly SuspicionMult distinct reporters, across a
+// range of cluster sizes.
+func TestHandleSuspectEscalatesAtConfiguredThreshold(t *testing.T) {
+	for _, mult := range []int{1, 2, 3, 5} {
+		mult := mult
+		t.Run(fmt.Sprintf("mult=%d", mult), func(t *testing.T) {
+			s := newTestSerf(&Config{NodeName: "local", SuspicionMult: mult})
+
+			mem := &Member{Name: "flaky", Status: StatusAlive}
+			s.members[mem.Name] = mem
+
+			for i := 0; i < mult-1; i++ {
+				s.handleSuspect(&suspect{Node: mem.Name, From: fmt.Sprintf("reporter-%d", i)})
+				if mem.Status == StatusFailed {
+					t.Fatalf("escalated to failed after only %d of %d confirmations", i+1, mult)
+				}
+			}
+
+			s.handleSuspect(&suspect{Node: mem.Name, From: fmt.Sprintf("reporter-%d", mult-1)})
+			if mem.Status != StatusFailed {
+				t.Fatalf("expected member to be failed after %d confirmations, got %v", mult, mem.Status)
+			}
+		})
+	}
+}
+
+// TestHandleSuspectIgnoresStaleIncarnation verifies that a suspect
+// message carrying a stale incarnation is dropped without affecting
+// the member's current status.
+func TestHandleSuspectIgnoresStaleIncarnation(t *testing.T) {
+	s := newTestSerf(&Config{NodeName: "local"})
+
+	mem := &Member{Name: "m1", Status: StatusAlive, Incarnation: 5}
+	s.members[mem.Name] = mem
+
+	if rebroadcast := s.handleSuspect(&suspect{Node: mem.Name, From: "other", Incarnation: 4}); rebroadcast {
+		t.Fatal("expected stale suspect message not to be rebroadcast")
+	}
+	if mem.Status != StatusAlive {
+		t.Fatalf("expected member to remain alive, got %v", mem.Status)
+	}
+}
@@ -0,0 +1,112 @@
+package serf
+
+import "fmt"
+
+// userEvent is a named, application-defined payload broadcast to the
+// rest of the cluster via Serf.UserEvent. It is ordered by a Lamport
+// clock rather than wall-clock time, so that delivery order is
+// consistent even across clock skew.
+type userEvent struct {
+	LTime   LamportTime
+	Name    string
+	Payload []byte
+}
+
+// userEventBufferSize bounds how many recent Lamport times are kept
+// per event name. Anything older than the buffer, relative to the
+// current clock, is dropped as replay noise rather than remembered.
+const userEventBufferSize = 512
+
+// defaultUserEventSizeLimit is used when Config.UserEventSizeLimit is
+// left at its zero value.
+const defaultUserEventSizeLimit = 512
+
+// UserEvent broadcasts a named payload to the rest of the cluster.
+// If coalesce is true, the delegate's UserEvent callback is batched
+// together with membership events in the same coalescing window as
+// changeHandler; otherwise it fires as soon as it is processed.
+func (s *Serf) UserEvent(name string, payload []byte, coalesce bool) error {
+	limit := s.conf.UserEventSizeLimit
+	if limit == 0 {
+		limit = defaultUserEventSizeLimit
+	}
+	if len(payload) > limit {
+		return fmt.Errorf("user event payload exceeds size limit of %d bytes", limit)
+	}
+
+	ev := userEvent{
+		LTime:   s.eventClock.Increment(),
+		Name:    name,
+		Payload: payload,
+	}
+
+	s.recordEvent(ev)
+
+	if err := s.queueBroadcast(messageUserEventType, &ev); err != nil {
+		return err
+	}
+
+	s.deliverUserEvent(ev, coalesce)
+	return nil
+}
+
+// handleRemoteUserEvent processes a user event received via gossip:
+// it witnesses the event's Lamport time, drops it if it is a replay
+// of one already seen, and otherwise re-broadcasts and delivers it.
+func (s *Serf) handleRemoteUserEvent(ev userEvent) {
+	s.eventClock.Witness(ev.LTime)
+
+	if !s.recordEvent(ev) {
+		return
+	}
+
+	s.queueBroadcast(messageUserEventType, &ev)
+	s.deliverUserEvent(ev, true)
+}
+
+// recordEvent checks the recent-event cache for ev, recording it if
+// new. It returns false if ev is either a duplicate already held in
+// the cache, or old enough relative to the current Lamport time that
+// it must be late-arriving gossip from before convergence.
+func (s *Serf) recordEvent(ev userEvent) bool {
+	s.eventLock.Lock()
+	defer s.eventLock.Unlock()
+
+	if s.recentEvents == nil {
+		s.recentEvents = make(map[string][]LamportTime)
+	}
+
+	if cur := s.eventClock.Time(); ev.LTime+userEventBufferSize < cur {
+		return false
+	}
+
+	seen := s.recentEvents[ev.Name]
+	for _, lt := range seen {
+		if lt == ev.LTime {
+			return false
+		}
+	}
+
+	seen = append(seen, ev.LTime)
+	if len(seen) > userEventBufferSize {
+		seen = seen[len(seen)-userEventBufferSize:]
+	}
+	s.recentEvents[ev.Name] = seen
+
+	return true
+}
+
+// deliverUserEvent hands ev to the configured delegate, either
+// immediately or via the same coalescing loop used for membership
+// events, depending on coalesce.
+func (s *Serf) deliverUserEvent(ev userEvent, coalesce bool) {
+	if coalesce {
+		select {
+		case s.eventCh <- ev:
+		case <-s.shutdownCh:
+		}
+		return
+	}
+
+	s.publish(UserEvent{LTime: ev.LTime, Name: ev.Name, Payload: ev.Payload})
+}
@@ -0,0 +1,40 @@
+package serf
+
+import "testing"
+
+// TestRecordEventDedup verifies that recordEvent accepts a
+// previously unseen Lamport time for an event name and rejects a
+// replay of one already recorded.
+func TestRecordEventDedup(t *testing.T) {
+	s := newTestSerf(&Config{NodeName: "local"})
+
+	ev := userEvent{LTime: 1, Name: "deploy", Payload: []byte("v1")}
+
+	if !s.recordEvent(ev) {
+		t.Fatal("expected first occurrence of event to be recorded")
+	}
+	if s.recordEvent(ev) {
+		t.Fatal("expected replay of the same event to be rejected as a duplicate")
+	}
+
+	next := userEvent{LTime: 2, Name: "deploy", Payload: []byte("v2")}
+	if !s.recordEvent(next) {
+		t.Fatal("expected a new Lamport time for the same event name to be recorded")
+	}
+}
+
+// TestRecordEventDropsOldReplay verifies that an event far enough
+// behind the current Lamport clock is dropped as late-arriving replay
+// noise, even though its exact LTime was never seen before.
+func TestRecordEventDropsOldReplay(t *testing.T) {
+	s := newTestSerf(&Config{NodeName: "local"})
+
+	for i := 0; i < userEventBufferSize+10; i++ {
+		s.eventClock.Increment()
+	}
+
+	stale := userEvent{LTime: 1, Name: "deploy", Payload: []byte("v1")}
+	if s.recordEvent(stale) {
+		t.Fatal("expected event far behind the current clock to be dropped")
+	}
+}